@@ -6,9 +6,93 @@
 //
 // Usage:
 //
-//	swippy {advanced|category|keyword|product|ebay-store} params
+//	swippy [flags] {advanced|category|keyword|product|ebay-store} [params]
 //
-// The “EBAY_APP_ID” and “DB_URL” environment variables are required.
+// The params argument is a query string of eBay Finding API parameters. Keys
+// and values may be URL-encoded, and repeated keys are numbered in order, so
+// “outputSelector=SellerInfo&outputSelector=GalleryInfo” is sent as
+// “outputSelector(0)” and “outputSelector(1)”. The parameters may instead be
+// given as a JSON object of string keys and values.
+//
+// If params is “-”, the parameters are read from standard input. The -f flag
+// reads them from a file instead, in which case params is omitted.
+//
+// The -since flag restricts results to items modified after the given time by
+// adding a ModTimeFrom item filter. The time is either a duration before now,
+// such as “24h”, or an RFC 3339 time.
+//
+// The -watch flag repeats the search at the given interval until swippy is
+// interrupted, reusing the database connection pool. Errors are logged
+// without stopping the loop. On interrupt, the search in progress is
+// cancelled, any unfinished insert is rolled back, and the database is
+// closed. A -since duration is re-evaluated on each run, so
+// “-watch 1h -since 1h” collects only items modified since the previous poll.
+// The -max-conns flag limits the number of open database connections.
+//
+// The -max-results flag pages through results until the given number of items
+// has been retrieved or no pages remain, trimming the last page as needed.
+// Paging starts at paginationInput.pageNumber if given, or page 1. The limit
+// counts items as returned by eBay, so fewer items are written or stored if
+// some are skipped because they cannot be converted. Without it, only the
+// requested page is retrieved.
+//
+// Logs are written to standard error as structured records. The -log-format
+// flag selects “text” (the default) or “json” records.
+//
+// Swippy exits with status 2 for command line usage errors, 3 for invalid
+// parameters rejected by swippy or eBay, 4 for eBay server or network
+// failures, and 1 for any other error, such as a database failure.
+//
+// The -dry-run flag prints the eBay request URL that would be sent and exits
+// without contacting eBay or the database. It cannot be combined with -watch.
+//
+// The -debug flag dumps each eBay HTTP request and response, including the
+// response body, to standard error. The dumped URLs contain the application
+// ID.
+//
+// The eBay application ID is read from the “EBAY_APP_ID” environment variable
+// or, if given, the -app-id flag; one of them is required. The “DB_URL”
+// environment variable is required unless -o or -template is given.
+//
+// The -o flag writes the retrieved items to standard output in the given
+// format. With “json”, it writes an array of objects keyed by database column
+// name. With “csv”, it writes a header row of database column names followed by
+// one row per item, leaving missing fields empty. Items are still stored when
+// “DB_URL” is set.
+//
+// The -template flag instead writes each item followed by a newline using a
+// text/template. The template's data maps database column names to values,
+// with missing fields set to nil; {{default "none" .location}} substitutes a
+// value for a missing field. Like -o, it makes “DB_URL” optional.
+//
+// The -dedupe flag controls how repeated listings are stored. With “latest”,
+// an item already in the database is updated in place with the newest
+// snapshot. With “history”, one row is kept per item and response timestamp,
+// so re-running the same search does not duplicate rows. Both modes require
+// the matching unique index from the sql directory. By default every result
+// is appended.
+//
+// Each item's “content_hash” column holds a SHA-256 hash of its fields,
+// ignoring the response timestamp, version, and time left, so unchanged
+// listings have equal hashes across searches. With “-dedupe latest”, a stored
+// item is updated only when its hash differs. Existing databases need
+// sql/add-item-content-hash.sql.
+//
+// The -store-raw flag also stores each item as JSON in the “raw_response”
+// column, so fields swippy does not extract can be queried later. The JSON is
+// re-encoded from the decoded item, so it holds only the fields modeled by
+// the github.com/matthewdargan/ebay package, not the original response bytes.
+// Existing databases need the migration in sql/add-item-raw-response.sql.
+//
+// The -columns flag limits the stored item columns to a comma-separated list
+// of names from sql/create-item.sql. Each name may appear once, and every
+// NOT NULL column must be included.
+//
+// Each stored batch of items is linked by “search_id” to a row in the
+// “search” table recording the method, parameters, time, and result count of
+// the invocation that produced it. The table in sql/create-search.sql must
+// be created before sql/create-item.sql, and existing databases need
+// sql/add-item-search-id.sql.
 //
 // Examples:
 //
@@ -19,109 +103,681 @@
 // Retrieve phones by category:
 //
 //	$ swippy category 'categoryId=9355'
+//
+// Keep only the latest snapshot of each phone:
+//
+//	$ swippy -dedupe latest keyword 'keywords=phone'
+//
+// Print the titles of phones without storing them:
+//
+//	$ swippy -o json keyword 'keywords=phone' | jq '.[].title'
+//
+// Export phones to a spreadsheet:
+//
+//	$ swippy -o csv keyword 'keywords=phone' >phones.csv
+//
+// Retrieve the first 250 phones across as many pages as needed:
+//
+//	$ swippy -max-results 250 keyword 'keywords=phone'
+//
+// Retrieve phones modified in the last day:
+//
+//	$ swippy -since 24h keyword 'keywords=phone'
+//
+// Print the title and price of each phone:
+//
+//	$ swippy -template '{{.title}}: {{.selling_status_current_price_value}}' keyword 'keywords=phone'
+//
+// Print the request URL for a keyword search:
+//
+//	$ swippy -dry-run keyword 'keywords=phone'
+//
+// Retrieve items using parameters stored in a file:
+//
+//	$ swippy -f params.json advanced
 package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"maps"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/lib/pq"
 	"github.com/matthewdargan/ebay"
 )
 
+const (
+	dedupeLatest  = "latest"
+	dedupeHistory = "history"
+)
+
+var (
+	appID      = flag.String("app-id", "", "use eBay application `ID` instead of $EBAY_APP_ID")
+	columns    = flag.String("columns", "", "store only the comma-separated item `columns`")
+	debug      = flag.Bool("debug", false, "dump eBay HTTP requests and responses to standard error")
+	dedupe     = flag.String("dedupe", "", "deduplicate stored items by `mode` (latest or history)")
+	dryRun     = flag.Bool("dry-run", false, "print the eBay request URL instead of sending it")
+	maxConns   = flag.Int("max-conns", 0, "limit open database connections to `n` (0 means unlimited)")
+	maxResults = flag.Int("max-results", 0, "page through results until `n` items are retrieved")
+	paramsFile = flag.String("f", "", "read params from `file`")
+	output     = flag.String("o", "", "write items to standard output in `format` (json or csv)")
+	tmpl       = flag.String("template", "", "write each item to standard output using template `text`")
+	since      = flag.String("since", "", "only retrieve items modified since `time`, a duration ago or an RFC 3339 time")
+	storeRaw   = flag.Bool("store-raw", false, "store each item's JSON in the raw_response column")
+	logFormat  = flag.String("log-format", "text", "write logs to standard error in `format` (text or json)")
+	watch      = flag.Duration("watch", 0, "repeat the search every `interval` until interrupted")
+)
+
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: swippy {advanced|category|keyword|product|ebay-store} params\n")
-	os.Exit(2)
+	fmt.Fprintf(os.Stderr, "usage: swippy [flags] {advanced|category|keyword|product|ebay-store} [params]\n")
+	flag.PrintDefaults()
+	os.Exit(exitUsage)
 }
 
 func main() {
-	log.SetPrefix("swippy: ")
-	log.SetFlags(0)
 	flag.Usage = usage
 	flag.Parse()
-	if flag.NArg() != 2 {
+	nargs := 2
+	if *paramsFile != "" {
+		nargs = 1
+	}
+	if flag.NArg() != nargs {
+		usage()
+	}
+	switch flag.Arg(0) {
+	case "advanced", "category", "keyword", "product", "ebay-store":
+	default:
+		usage()
+	}
+	if *dedupe != "" && *dedupe != dedupeLatest && *dedupe != dedupeHistory {
+		usage()
+	}
+	if *output != "" && *output != outputJSON && *output != outputCSV {
+		usage()
+	}
+	if *output != "" && *tmpl != "" {
+		usage()
+	}
+	if *dryRun && *watch != 0 {
 		usage()
 	}
-	queryParams, err := parseParams(flag.Arg(1))
+	if *watch < 0 || *maxConns < 0 || *maxResults < 0 {
+		usage()
+	}
+	switch *logFormat {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+	default:
+		usage()
+	}
+	id := *appID
+	if id == "" {
+		id = os.Getenv("EBAY_APP_ID")
+	}
+	if id == "" {
+		fatal(exitUsage, "missing eBay application ID: set -app-id or EBAY_APP_ID")
+	}
+	ps, err := readParams(*paramsFile, flag.Arg(1))
+	if err != nil {
+		fatal(exitError, "failed to read params", "err", err)
+	}
+	queryParams, err := parseParams(ps)
+	if err != nil {
+		fatal(exitInvalid, "invalid params", "err", err)
+	}
+	if *since != "" {
+		if _, err = parseSince(*since, time.Now()); err != nil {
+			fatal(exitUsage, "invalid flag", "err", err)
+		}
+	}
+	if *maxResults > 0 {
+		if _, err = startPage(queryParams); err != nil {
+			fatal(exitInvalid, "invalid params", "err", err)
+		}
+	}
+	var t *template.Template
+	if *tmpl != "" {
+		if t, err = newTemplate(*tmpl); err != nil {
+			fatal(exitUsage, "invalid flag", "err", err)
+		}
+	}
+	hc := &http.Client{Timeout: time.Second * 10}
+	if *dryRun {
+		hc.Transport = dryRunTransport{}
+	}
+	if *debug {
+		hc.Transport = dumpTransport{next: hc.Transport, w: os.Stderr}
+	}
+	c := ebay.NewFindingClient(hc, id)
+	store := storeOptions{dedupe: *dedupe, storeRaw: *storeRaw}
+	if *columns != "" {
+		if store.columns, err = parseColumns(*columns); err != nil {
+			fatal(exitUsage, "invalid flag", "err", err)
+		}
+	}
+	var db *sql.DB
+	if os.Getenv("DB_URL") != "" || *output == "" && *tmpl == "" {
+		db, err = sql.Open("postgres", os.Getenv("DB_URL"))
+		if err != nil {
+			fatal(exitError, "failed to connect to database", "err", err)
+		}
+		db.SetMaxOpenConns(*maxConns)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if *watch == 0 {
+		if err = run(ctx, c, db, store, t, flag.Arg(0), queryParams); err != nil {
+			fatal(exitCode(err), "search failed", "method", flag.Arg(0), "err", err)
+		}
+	} else {
+		tick := time.NewTicker(*watch)
+		for ctx.Err() == nil {
+			if err = run(ctx, c, db, store, t, flag.Arg(0), queryParams); err != nil && ctx.Err() == nil {
+				slog.Error("search failed", "method", flag.Arg(0), "err", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-tick.C:
+			}
+		}
+		tick.Stop()
+	}
+	stop()
+	if db != nil {
+		if err = db.Close(); err != nil {
+			fatal(exitError, "failed to close database", "err", err)
+		}
+	}
+}
+
+// maxClockSkew is how far an eBay response timestamp may be from the local
+// time before swippy warns that the response may be stale.
+const maxClockSkew = 5 * time.Minute
+
+// run performs one search and writes or stores the resulting items.
+// Items are written with t only if t is non-nil, and stored only if db is
+// non-nil.
+func run(ctx context.Context, c *ebay.FindingClient, db *sql.DB, store storeOptions, t *template.Template, method string, params map[string]string) error {
+	start := time.Now()
+	params = maps.Clone(params)
+	if *since != "" {
+		from, err := parseSince(*since, start)
+		if err != nil {
+			return err
+		}
+		addItemFilter(params, "ModTimeFrom", from.UTC().Format(ebayTimeLayout))
+	}
+	resps, err := findPages(ctx, c, method, params, *maxResults)
+	if err != nil {
+		return err
+	}
+	if len(resps) == 0 {
+		return nil
+	}
+	requested, _ := strconv.Atoi(params["paginationInput.pageNumber"])
+	for _, r := range resps {
+		if total, ok := totalPages(r); ok && requested > max(total, 1) {
+			slog.Warn("requested page is past the last page", "method", method, "page", requested, "totalPages", total)
+		}
+		if ts := first(r.Timestamp); !ts.IsZero() && ts.Sub(start).Abs() > maxClockSkew {
+			slog.Warn("eBay response timestamp differs from local time", "method", method, "timestamp", ts, "skew", ts.Sub(start))
+		}
+		switch ack := first(r.Ack); {
+		case ack == "Warning":
+			slog.Warn("eBay returned warnings", "method", method, "warnings", errorMessages(r.ErrorMessage))
+		case ack != "Success" && len(r.ErrorMessage) > 0:
+			return &failureError{r.ErrorMessage}
+		}
+	}
+	items := responsesToItems(resps, store.storeRaw)
+	if *output != "" {
+		if err = writeItems(os.Stdout, items, *output); err != nil {
+			return err
+		}
+	}
+	if t != nil {
+		if err = writeTemplate(os.Stdout, items, t); err != nil {
+			return err
+		}
+	}
+	if db != nil {
+		s := search{timestamp: start, method: method, params: params}
+		if err = insertItems(ctx, db, s, items, store); err != nil {
+			return err
+		}
+	}
+	slog.Info("search completed", "method", method, "params", len(params), "results", len(items), "duration", time.Since(start))
+	return nil
+}
+
+// Exit codes.
+const (
+	exitError   = 1 // database, output, or other local failure
+	exitUsage   = 2 // invalid command line
+	exitInvalid = 3 // invalid parameters, rejected by swippy or eBay
+	exitAPI     = 4 // eBay or network failure
+)
+
+// fatal logs msg at error level with the given attributes and exits with code.
+func fatal(code int, msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(code)
+}
+
+// A failureError reports the errors in an eBay response whose ack is not
+// Success or Warning.
+type failureError struct {
+	msgs []ebay.ErrorMessage
+}
+
+func (e *failureError) Error() string {
+	return errorMessages(e.msgs)
+}
+
+// exitCode returns the exit code for an error returned by run.
+func exitCode(err error) int {
+	var fe *failureError
+	if errors.As(err, &fe) {
+		for _, m := range fe.msgs {
+			for _, e := range m.Error {
+				if first(e.Category) == "System" {
+					return exitAPI
+				}
+			}
+		}
+		return exitInvalid
+	}
+	switch {
+	case errors.Is(err, ebay.ErrInvalidStatus):
+		if code := statusCode(err); code >= 400 && code < 500 {
+			return exitInvalid
+		}
+		return exitAPI
+	case errors.Is(err, ebay.ErrFailedRequest), errors.Is(err, ebay.ErrDecodeAPIResponse):
+		return exitAPI
+	}
+	return exitError
+}
+
+// statusCode extracts the HTTP status code from an [ebay.ErrInvalidStatus]
+// error, which the ebay package formats as "<message>: <code>".
+// It returns 0 if there is no code.
+func statusCode(err error) int {
+	msg := err.Error()
+	code, err := strconv.Atoi(msg[strings.LastIndex(msg, " ")+1:])
 	if err != nil {
-		log.Fatal(err)
+		return 0
+	}
+	return code
+}
+
+// maxPages is the highest page number the eBay Finding API returns.
+const maxPages = 100
+
+// findPages calls find for successive pages until maxResults items have been
+// retrieved, the last page is reached, or eBay reports an error. The final page
+// is trimmed so that exactly maxResults items are returned when that many
+// exist. Items are counted before conversion, so items that responseToItems
+// later skips still count toward maxResults. Paging starts at the page given
+// by startPage. If maxResults is 0, only the requested page is retrieved.
+func findPages(ctx context.Context, c *ebay.FindingClient, method string, params map[string]string, maxResults int) ([]ebay.FindItemsResponse, error) {
+	if maxResults == 0 {
+		return find(ctx, c, method, params)
+	}
+	start, err := startPage(params)
+	if err != nil {
+		return nil, err
+	}
+	params = maps.Clone(params)
+	if _, ok := params["paginationInput.entriesPerPage"]; !ok {
+		params["paginationInput.entriesPerPage"] = strconv.Itoa(min(maxResults, 100))
 	}
-	c := ebay.NewFindingClient(&http.Client{Timeout: time.Second * 10}, os.Getenv("EBAY_APP_ID"))
 	var resps []ebay.FindItemsResponse
-	switch flag.Arg(0) {
+	n := 0
+	for page := start; page <= maxPages; page++ {
+		params["paginationInput.pageNumber"] = strconv.Itoa(page)
+		var rs []ebay.FindItemsResponse
+		rs, err = find(ctx, c, method, params)
+		if err != nil {
+			return nil, err
+		}
+		if len(rs) == 0 {
+			break
+		}
+		r := rs[0]
+		resps = append(resps, r)
+		if first(r.Ack) == "Failure" || len(r.SearchResult) == 0 {
+			break
+		}
+		sr := &r.SearchResult[0]
+		if n+len(sr.Item) > maxResults {
+			sr.Item = sr.Item[:maxResults-n]
+		}
+		n += len(sr.Item)
+		total, ok := totalPages(r)
+		if !ok || n >= maxResults || page >= total {
+			break
+		}
+	}
+	return resps, nil
+}
+
+// startPage returns the paginationInput.pageNumber in params, or 1 if it is
+// not set.
+func startPage(params map[string]string) (int, error) {
+	s, ok := params["paginationInput.pageNumber"]
+	if !ok {
+		return 1, nil
+	}
+	page, err := strconv.Atoi(s)
+	if err != nil || page < 1 || page > maxPages {
+		return 0, fmt.Errorf("invalid paginationInput.pageNumber %q: must be between 1 and %d", s, maxPages)
+	}
+	return page, nil
+}
+
+// totalPages returns the total number of pages reported in r.
+// It reports false if r does not include a valid page count.
+func totalPages(r ebay.FindItemsResponse) (int, bool) {
+	n, err := strconv.Atoi(first(first(r.PaginationOutput).TotalPages))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// find calls the eBay Finding API operation named by method.
+func find(ctx context.Context, c *ebay.FindingClient, method string, params map[string]string) ([]ebay.FindItemsResponse, error) {
+	switch method {
 	case "advanced":
-		var r *ebay.FindItemsAdvancedResponse
-		r, err = c.FindItemsAdvanced(context.Background(), queryParams)
+		r, err := c.FindItemsAdvanced(ctx, params)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		resps = r.ItemsResponse
+		return r.ItemsResponse, nil
 	case "category":
-		var r *ebay.FindItemsByCategoryResponse
-		r, err = c.FindItemsByCategory(context.Background(), queryParams)
+		r, err := c.FindItemsByCategory(ctx, params)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		resps = r.ItemsResponse
+		return r.ItemsResponse, nil
 	case "keyword":
-		var r *ebay.FindItemsByKeywordsResponse
-		r, err = c.FindItemsByKeywords(context.Background(), queryParams)
+		r, err := c.FindItemsByKeywords(ctx, params)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		resps = r.ItemsResponse
+		return r.ItemsResponse, nil
 	case "product":
-		var r *ebay.FindItemsByProductResponse
-		r, err = c.FindItemsByProduct(context.Background(), queryParams)
+		r, err := c.FindItemsByProduct(ctx, params)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		resps = r.ItemsResponse
+		return r.ItemsResponse, nil
 	case "ebay-store":
-		var r *ebay.FindItemsInEBayStoresResponse
-		r, err = c.FindItemsInEBayStores(context.Background(), queryParams)
+		r, err := c.FindItemsInEBayStores(ctx, params)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		resps = r.ItemsResponse
-	default:
-		usage()
+		return r.ItemsResponse, nil
 	}
-	if len(resps) == 0 {
-		os.Exit(0)
+	return nil, fmt.Errorf("unknown method %q", method)
+}
+
+// errorMessages formats the errors and warnings in an eBay response as
+// "errorId: message" pairs separated by semicolons.
+func errorMessages(ms []ebay.ErrorMessage) string {
+	var msgs []string
+	for _, m := range ms {
+		for _, e := range m.Error {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", first(e.ErrorID), first(e.Message)))
+		}
 	}
-	if len(resps[0].ErrorMessage) > 0 {
-		log.Fatal(resps[0].ErrorMessage)
+	return strings.Join(msgs, "; ")
+}
+
+// ebayTimeLayout is the UTC time format the eBay Finding API expects in
+// item filter values.
+const ebayTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// parseSince parses s as either a duration before now, such as 24h, or an
+// RFC 3339 time. The result must not be after now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		d, derr := time.ParseDuration(s)
+		if derr != nil {
+			return time.Time{}, fmt.Errorf("invalid -since %q: must be a duration or RFC 3339 time", s)
+		}
+		t = now.Add(-d)
+	}
+	if t.After(now) {
+		return time.Time{}, fmt.Errorf("invalid -since %q: time is in the future", s)
 	}
-	log.Print(resps)
-	db, err := sql.Open("postgres", os.Getenv("DB_URL"))
+	return t, nil
+}
+
+// addItemFilter appends an item filter with the given name and value to
+// params, numbering it after any existing item filters. A non-numbered item
+// filter already in params is renumbered to itemFilter(0), since eBay does not
+// accept both forms in one request.
+func addItemFilter(params map[string]string, name, value string) {
+	n := 0
+	for k, v := range params {
+		if rest, ok := strings.CutPrefix(k, "itemFilter."); ok {
+			delete(params, k)
+			params["itemFilter(0)."+rest] = v
+			n = max(n, 1)
+			continue
+		}
+		rest, ok := strings.CutPrefix(k, "itemFilter(")
+		if !ok {
+			continue
+		}
+		idx, _, ok := strings.Cut(rest, ")")
+		if !ok {
+			continue
+		}
+		if i, err := strconv.Atoi(idx); err == nil {
+			n = max(n, i+1)
+		}
+	}
+	params[fmt.Sprintf("itemFilter(%d).name", n)] = name
+	params[fmt.Sprintf("itemFilter(%d).value", n)] = value
+}
+
+// dumpTransport writes each request and response, including the response
+// body, to w. A nil next uses http.DefaultTransport.
+type dumpTransport struct {
+	next http.RoundTripper
+	w    io.Writer
+}
+
+func (t dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = t.w.Write(b); err != nil {
+		return nil, err
+	}
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		return nil, err
 	}
-	if err := insertItems(db, resps); err != nil {
-		log.Fatal(err)
+	// DumpResponse replaces resp.Body, so the response can still be decoded.
+	if b, err = httputil.DumpResponse(resp, true); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if _, err = fmt.Fprintf(t.w, "%s\n\n", b); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// dryRunTransport prints each request URL to standard output and responds
+// with an empty result instead of sending the request.
+type dryRunTransport struct{}
+
+func (dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fmt.Println(req.URL)
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("{}")),
+		Request:    req,
+	}, nil
+}
+
+// readParams returns the params string from file if it is set, from standard
+// input if arg is "-", or arg itself otherwise.
+func readParams(file, arg string) (string, error) {
+	var b []byte
+	var err error
+	switch {
+	case file != "":
+		b, err = os.ReadFile(file)
+	case arg == "-":
+		b, err = io.ReadAll(os.Stdin)
+	default:
+		return arg, nil
 	}
-	if err := db.Close(); err != nil {
-		log.Fatal(err)
+	if err != nil {
+		return "", err
 	}
+	return string(b), nil
 }
 
+// parseParams parses a query string of the form k1=v1&k2=v2 into request
+// parameters. Keys and values may be URL-encoded. If ps is a JSON object, its
+// string keys and values are used as is.
+//
+// Repeated keys are numbered in the order they appear, so
+// outputSelector=A&outputSelector=B becomes outputSelector(0) and
+// outputSelector(1). For itemFilter and aspectFilter keys, each name or
+// aspectName field respectively starts a new numbered entry, and repeated
+// fields within an entry are numbered as well:
+//
+//	itemFilter.name=Condition&itemFilter.value=New&itemFilter.value=Used&itemFilter.name=FreeShippingOnly&itemFilter.value=true
+//
+// becomes itemFilter(0).name, itemFilter(0).value(0), itemFilter(0).value(1),
+// itemFilter(1).name, and itemFilter(1).value. It is an error for another
+// filter field to precede the first name or aspectName, or for a numbered key
+// to match one given explicitly.
 func parseParams(ps string) (map[string]string, error) {
-	params := make(map[string]string)
+	ps = strings.TrimSpace(ps)
+	if strings.HasPrefix(ps, "{") {
+		var params map[string]string
+		if err := json.Unmarshal([]byte(ps), &params); err != nil {
+			return nil, fmt.Errorf("invalid JSON params: %w", err)
+		}
+		return params, nil
+	}
+	var keys, vals []string
 	for _, p := range strings.Split(ps, "&") {
-		parts := strings.Split(p, "=")
-		if len(parts) != 2 {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
 			return nil, fmt.Errorf("invalid parameter %q", p)
 		}
-		params[parts[0]] = parts[1]
+		k, err := url.QueryUnescape(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter %q: %w", p, err)
+		}
+		v, err = url.QueryUnescape(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter %q: %w", p, err)
+		}
+		keys = append(keys, k)
+		vals = append(vals, v)
+	}
+	return numberParams(keys, vals)
+}
+
+type paramField struct {
+	prefix string
+	entry  int
+	field  string
+}
+
+// entryFields maps each dotted parameter prefix that may repeat as a group to
+// the field that starts a new entry.
+var entryFields = map[string]string{
+	"aspectFilter": "aspectName",
+	"itemFilter":   "name",
+}
+
+func numberParams(keys, vals []string) (map[string]string, error) {
+	counts := make(map[string]int)
+	entries := make(map[string]int)
+	fieldCounts := make(map[paramField]int)
+	fields := make([]paramField, len(keys))
+	for i, k := range keys {
+		counts[k]++
+		prefix, field, ok := strings.Cut(k, ".")
+		if !ok {
+			continue
+		}
+		if lead, grouped := entryFields[prefix]; grouped {
+			if field == lead {
+				entries[prefix]++
+			} else if entries[prefix] == 0 {
+				return nil, fmt.Errorf("parameter %q must follow %s.%s", k, prefix, lead)
+			}
+		}
+		fields[i] = paramField{prefix: prefix, entry: max(entries[prefix]-1, 0), field: field}
+		fieldCounts[fields[i]]++
+	}
+	params := make(map[string]string, len(keys))
+	seen := make(map[string]int)
+	fieldSeen := make(map[paramField]int)
+	for i, k := range keys {
+		f := fields[i]
+		if f.prefix == "" {
+			if counts[k] > 1 {
+				k = fmt.Sprintf("%s(%d)", k, seen[k])
+				seen[keys[i]]++
+			}
+		} else {
+			prefix, field := f.prefix, f.field
+			if entries[prefix] > 1 {
+				prefix = fmt.Sprintf("%s(%d)", prefix, f.entry)
+			}
+			if fieldCounts[f] > 1 {
+				field = fmt.Sprintf("%s(%d)", field, fieldSeen[f])
+				fieldSeen[f]++
+			}
+			k = prefix + "." + field
+		}
+		if _, ok := params[k]; ok {
+			return nil, fmt.Errorf("parameter %q is given more than once", k)
+		}
+		params[k] = vals[i]
 	}
 	return params, nil
 }
@@ -129,25 +785,42 @@ func parseParams(ps string) (map[string]string, error) {
 type eBayItem struct {
 	timestamp                                  time.Time
 	version                                    string
-	conditionDisplayName                       string
-	conditionID                                int
+	autoPay                                    *bool
+	conditionDisplayName                       *string
+	conditionID                                *int
+	contentHash                                string
 	country                                    string
+	distanceUnit                               *string
+	distanceValue                              *float64
+	expeditedShipping                          *bool
 	galleryURL                                 *string
+	galleryURLLarge                            *string
+	galleryURLMedium                           *string
+	galleryURLSmall                            *string
 	globalID                                   string
+	handlingTime                               *int
 	isMultiVariationListing                    bool
 	itemID                                     int64
 	listingInfoBestOfferEnabled                bool
 	listingInfoBuyItNowAvailable               bool
 	listingInfoEndTime                         time.Time
+	listingInfoGift                            *bool
 	listingInfoListingType                     string
 	listingInfoStartTime                       time.Time
 	listingInfoWatchCount                      *int
 	location                                   *string
+	oneDayShippingAvailable                    *bool
+	pictureURLs                                []string
 	postalCode                                 *string
 	primaryCategoryID                          int
 	primaryCategoryName                        string
 	productIDType                              *string
-	productIDValue                             *int64
+	productIDValue                             *string
+	returnsAccepted                            *bool
+	sellerFeedbackScore                        *int
+	sellerPositiveFeedbackPercent              *float64
+	sellerTopRated                             *bool
+	sellerUserName                             *string
 	sellingStatusConvertedCurrentPriceCurrency *string
 	sellingStatusConvertedCurrentPriceValue    *float64
 	sellingStatusCurrentPriceCurrency          *string
@@ -157,214 +830,643 @@ type eBayItem struct {
 	shippingServiceCostCurrency                *string
 	shippingServiceCostValue                   *float64
 	shippingType                               *string
-	shipToLocations                            *string
+	shipToLocations                            []string
 	subtitle                                   *string
 	title                                      string
 	topRatedListing                            bool
 	viewItemURL                                *string
+	raw                                        json.RawMessage
+}
+
+var itemColumns = []string{
+	"timestamp", "version", "auto_pay", "condition_display_name",
+	"condition_id", "content_hash", "country", "distance_unit",
+	"distance_value", "expedited_shipping", "gallery_url",
+	"gallery_url_large", "gallery_url_medium", "gallery_url_small", "global_id",
+	"handling_time", "is_multi_variation_listing", "item_id",
+	"listing_info_best_offer_enabled",
+	"listing_info_buy_it_now_available", "listing_info_end_time",
+	"listing_info_gift", "listing_info_listing_type", "listing_info_start_time",
+	"listing_info_watch_count", "location", "one_day_shipping_available",
+	"picture_urls", "postal_code",
+	"primary_category_id", "primary_category_name", "product_id_type",
+	"product_id_value", "returns_accepted", "seller_feedback_score",
+	"seller_positive_feedback_percent", "seller_top_rated", "seller_user_name",
+	"selling_status_converted_current_price_currency",
+	"selling_status_converted_current_price_value",
+	"selling_status_current_price_currency",
+	"selling_status_current_price_value", "selling_status_selling_state",
+	"selling_status_time_left", "shipping_service_cost_currency",
+	"shipping_service_cost_value", "shipping_type", "ship_to_locations",
+	"subtitle", "title", "top_rated_listing", "view_item_url",
 }
 
-func insertItems(db *sql.DB, rs []ebay.FindItemsResponse) error {
+// values returns the item's fields in itemColumns order.
+func (it eBayItem) values() []any {
+	return []any{
+		it.timestamp, it.version, it.autoPay, it.conditionDisplayName,
+		it.conditionID, it.contentHash, it.country, it.distanceUnit,
+		it.distanceValue, it.expeditedShipping,
+		it.galleryURL, it.galleryURLLarge, it.galleryURLMedium,
+		it.galleryURLSmall, it.globalID, it.handlingTime,
+		it.isMultiVariationListing, it.itemID,
+		it.listingInfoBestOfferEnabled, it.listingInfoBuyItNowAvailable,
+		it.listingInfoEndTime, it.listingInfoGift, it.listingInfoListingType,
+		it.listingInfoStartTime, it.listingInfoWatchCount, it.location,
+		it.oneDayShippingAvailable, it.pictureURLs, it.postalCode,
+		it.primaryCategoryID,
+		it.primaryCategoryName, it.productIDType, it.productIDValue,
+		it.returnsAccepted, it.sellerFeedbackScore,
+		it.sellerPositiveFeedbackPercent, it.sellerTopRated, it.sellerUserName,
+		it.sellingStatusConvertedCurrentPriceCurrency,
+		it.sellingStatusConvertedCurrentPriceValue,
+		it.sellingStatusCurrentPriceCurrency, it.sellingStatusCurrentPriceValue,
+		it.sellingStatusSellingState, it.sellingStatusTimeLeft,
+		it.shippingServiceCostCurrency, it.shippingServiceCostValue,
+		it.shippingType, it.shipToLocations, it.subtitle, it.title,
+		it.topRatedListing, it.viewItemURL,
+	}
+}
+
+// unhashedColumns lists the columns that Hash ignores because they change
+// between retrievals of an unchanged listing.
+var unhashedColumns = []string{"timestamp", "version", "content_hash", "selling_status_time_left"}
+
+// Hash returns the hex-encoded SHA-256 hash of the item's fields, excluding
+// unhashedColumns. Items with equal hashes describe the same listing state.
+// The hash is computed from hashValue encodings, so it does not depend on
+// how items are formatted for output.
+func (it eBayItem) Hash() string {
+	h := sha256.New()
+	for i, v := range it.values() {
+		if slices.Contains(unhashedColumns, itemColumns[i]) {
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00", itemColumns[i], hashValue(v))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashValue returns a canonical encoding of an item field value for Hash.
+// Strings are quoted so that a missing value, encoded as null, differs from
+// an empty one.
+func hashValue(v any) string {
+	switch v := deref(v).(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case []string:
+		qs := make([]string, len(v))
+		for i, s := range v {
+			qs[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(qs, ",") + "]"
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprintf("%#v", v)
+	}
+}
+
+func responsesToItems(rs []ebay.FindItemsResponse, raw bool) []eBayItem {
 	var eBayItems []eBayItem
 	for _, r := range rs {
-		items, err := responseToItems(r)
+		items, err := responseToItems(r, raw)
 		if err != nil {
-			log.Printf("failed to convert eBay API response to items: %v", err)
+			slog.Error("failed to convert eBay API response to items", "err", err)
 			continue
 		}
 		eBayItems = append(eBayItems, items...)
 	}
-	txn, err := db.Begin()
+	return eBayItems
+}
+
+// A search describes the swippy invocation that produced a set of items.
+type search struct {
+	timestamp time.Time
+	method    string
+	params    map[string]string
+}
+
+// insertBatchSize is the maximum number of items sent in one COPY or
+// prepared statement.
+const insertBatchSize = 1000
+
+// storeOptions controls how items are stored.
+type storeOptions struct {
+	// columns lists the item columns to store. If nil, all of itemColumns are
+	// stored.
+	columns  []string
+	dedupe   string
+	storeRaw bool
+}
+
+// insertItems stores the items from search s in a single transaction, which is
+// rolled back if any batch fails.
+func insertItems(ctx context.Context, db *sql.DB, s search, eBayItems []eBayItem, opts storeOptions) error {
+	txn, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	stmt, err := txn.Prepare(pq.CopyIn(
-		"item", "timestamp", "version", "condition_display_name",
-		"condition_id", "country", "gallery_url", "global_id",
-		"is_multi_variation_listing", "item_id",
-		"listing_info_best_offer_enabled", "listing_info_buy_it_now_available",
-		"listing_info_end_time", "listing_info_listing_type",
-		"listing_info_start_time", "listing_info_watch_count", "location",
-		"postal_code", "primary_category_id", "primary_category_name",
-		"product_id_type", "product_id_value",
-		"selling_status_converted_current_price_currency",
-		"selling_status_converted_current_price_value",
-		"selling_status_current_price_currency",
-		"selling_status_current_price_value", "selling_status_selling_state",
-		"selling_status_time_left", "shipping_service_cost_currency",
-		"shipping_service_cost_value", "shipping_type", "ship_to_locations",
-		"subtitle", "title", "top_rated_listing", "view_item_url"))
+	if err = insertItemsTx(ctx, txn, s, eBayItems, opts); err != nil {
+		if rerr := txn.Rollback(); rerr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rerr)
+		}
+		return err
+	}
+	return txn.Commit()
+}
+
+func insertItemsTx(ctx context.Context, txn *sql.Tx, s search, eBayItems []eBayItem, opts storeOptions) error {
+	params, err := json.Marshal(s.params)
 	if err != nil {
 		return err
 	}
-	for _, it := range eBayItems {
-		_, err = stmt.Exec(
-			it.timestamp, it.version, it.conditionDisplayName, it.conditionID,
-			it.country, it.galleryURL, it.globalID, it.isMultiVariationListing,
-			it.itemID, it.listingInfoBestOfferEnabled,
-			it.listingInfoBuyItNowAvailable, it.listingInfoEndTime,
-			it.listingInfoListingType, it.listingInfoStartTime,
-			it.listingInfoWatchCount, it.location, it.postalCode,
-			it.primaryCategoryID, it.primaryCategoryName, it.productIDType,
-			it.productIDValue, it.sellingStatusConvertedCurrentPriceCurrency,
-			it.sellingStatusConvertedCurrentPriceValue,
-			it.sellingStatusCurrentPriceCurrency,
-			it.sellingStatusCurrentPriceValue, it.sellingStatusSellingState,
-			it.sellingStatusTimeLeft, it.shippingServiceCostCurrency,
-			it.shippingServiceCostValue, it.shippingType, it.shipToLocations,
-			it.subtitle, it.title, it.topRatedListing, it.viewItemURL,
-		)
-		if err != nil {
+	var searchID int
+	err = txn.QueryRowContext(ctx,
+		"INSERT INTO search (timestamp, method, params, result_count) VALUES ($1, $2, $3, $4) RETURNING id",
+		s.timestamp, s.method, string(params), len(eBayItems),
+	).Scan(&searchID)
+	if err != nil {
+		return err
+	}
+	columns := opts.columns
+	if columns == nil {
+		columns = itemColumns
+	}
+	idx := make([]int, len(columns))
+	for i, c := range columns {
+		idx[i] = slices.Index(itemColumns, c)
+	}
+	columns = append(columns[:len(columns):len(columns)], "search_id")
+	if opts.storeRaw {
+		columns = append(columns, "raw_response")
+	}
+	row := func(it eBayItem) []any {
+		all := it.values()
+		vs := make([]any, 0, len(columns))
+		for _, i := range idx {
+			v := all[i]
+			if ss, ok := v.([]string); ok {
+				v = pq.Array(ss)
+			}
+			vs = append(vs, v)
+		}
+		vs = append(vs, searchID)
+		if opts.storeRaw {
+			vs = append(vs, string(it.raw))
+		}
+		return vs
+	}
+	var query string
+	switch opts.dedupe {
+	case dedupeLatest:
+		query = upsertQuery(columns, []string{"item_id"}, true)
+	case dedupeHistory:
+		query = upsertQuery(columns, []string{"item_id", "timestamp"}, false)
+	default:
+		query = pq.CopyIn("item", columns...)
+	}
+	for i := 0; i < len(eBayItems); i += insertBatchSize {
+		batch := eBayItems[i:min(i+insertBatchSize, len(eBayItems))]
+		if err = insertBatch(ctx, txn, query, batch, row, opts.dedupe == ""); err != nil {
 			return err
 		}
 	}
-	if _, err = stmt.Exec(); err != nil {
+	return nil
+}
+
+func insertBatch(ctx context.Context, txn *sql.Tx, query string, eBayItems []eBayItem, row func(eBayItem) []any, copyIn bool) error {
+	stmt, err := txn.PrepareContext(ctx, query)
+	if err != nil {
 		return err
 	}
-	if err = stmt.Close(); err != nil {
-		return err
+	for _, it := range eBayItems {
+		if _, err = stmt.ExecContext(ctx, row(it)...); err != nil {
+			return err
+		}
 	}
-	return txn.Commit()
+	if copyIn {
+		if _, err = stmt.ExecContext(ctx); err != nil {
+			return err
+		}
+	}
+	return stmt.Close()
 }
 
-func responseToItems(resp ebay.FindItemsResponse) ([]eBayItem, error) {
-	items := make([]eBayItem, len(resp.SearchResult[0].Item))
-	for i := range items {
-		it, err := item(resp.SearchResult[0].Item[i])
+// requiredColumns lists the item columns declared NOT NULL in
+// sql/create-item.sql.
+var requiredColumns = []string{
+	"timestamp", "version", "country", "global_id",
+	"is_multi_variation_listing", "item_id", "listing_info_best_offer_enabled",
+	"listing_info_buy_it_now_available", "listing_info_end_time",
+	"listing_info_listing_type", "listing_info_start_time",
+	"primary_category_id", "primary_category_name", "title",
+	"top_rated_listing",
+}
+
+// parseColumns parses a comma-separated list of item columns to store.
+// Each column must be in itemColumns and appear once, and every column in
+// requiredColumns must be included.
+func parseColumns(s string) ([]string, error) {
+	columns := strings.Split(s, ",")
+	for i, c := range columns {
+		columns[i] = strings.TrimSpace(c)
+		if !slices.Contains(itemColumns, columns[i]) {
+			return nil, fmt.Errorf("unknown column %q", columns[i])
+		}
+		if slices.Contains(columns[:i], columns[i]) {
+			return nil, fmt.Errorf("duplicate column %q", columns[i])
+		}
+	}
+	for _, c := range requiredColumns {
+		if !slices.Contains(columns, c) {
+			return nil, fmt.Errorf("missing required column %q", c)
+		}
+	}
+	return columns, nil
+}
+
+// upsertQuery returns an INSERT statement for a single item that resolves
+// conflicts on the conflict columns by updating the existing row or, if update
+// is false, by skipping the new one.
+func upsertQuery(columns, conflict []string, update bool) string {
+	cols := make([]string, len(columns))
+	params := make([]string, len(columns))
+	sets := make([]string, len(columns))
+	for i, c := range columns {
+		cols[i] = pq.QuoteIdentifier(c)
+		params[i] = "$" + strconv.Itoa(i+1)
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", cols[i], cols[i])
+	}
+	conflictCols := make([]string, len(conflict))
+	for i, c := range conflict {
+		conflictCols[i] = pq.QuoteIdentifier(c)
+	}
+	q := fmt.Sprintf("INSERT INTO item (%s) VALUES (%s) ON CONFLICT (%s) DO ",
+		strings.Join(cols, ", "), strings.Join(params, ", "), strings.Join(conflictCols, ", "))
+	if !update {
+		return q + "NOTHING"
+	}
+	q += "UPDATE SET " + strings.Join(sets, ", ")
+	if slices.Contains(columns, "content_hash") {
+		q += " WHERE item.content_hash IS DISTINCT FROM EXCLUDED.content_hash"
+	}
+	return q
+}
+
+// responseToItems converts the items in resp. Items that cannot be converted
+// are logged and skipped. If raw is true, each decoded item is also
+// re-encoded as JSON for the raw_response column.
+func responseToItems(resp ebay.FindItemsResponse, raw bool) ([]eBayItem, error) {
+	if len(resp.SearchResult) == 0 || len(resp.SearchResult[0].Item) == 0 {
+		return nil, nil
+	}
+	timestamp, err := elem(resp.Timestamp, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+	version, err := elem(resp.Version, "version")
+	if err != nil {
+		return nil, err
+	}
+	var items []eBayItem
+	for _, sItem := range resp.SearchResult[0].Item {
+		var it eBayItem
+		it, err = item(sItem)
 		if err != nil {
-			return nil, err
+			slog.Error("failed to convert eBay item", "itemId", first(sItem.ItemID), "err", err)
+			continue
+		}
+		if raw {
+			if it.raw, err = json.Marshal(sItem); err != nil {
+				return nil, err
+			}
 		}
-		it.timestamp = resp.Timestamp[0]
-		it.version = resp.Version[0]
-		items[i] = it
+		it.timestamp = timestamp
+		it.version = version
+		it.contentHash = it.Hash()
+		items = append(items, it)
 	}
 	return items, nil
 }
 
 func item(it ebay.SearchItem) (eBayItem, error) {
-	conditionID, err := strconv.Atoi(it.Condition[0].ConditionID[0])
+	autoPay, err := optionalBool(it.AutoPay, "autoPay")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	returnsAccepted, err := optionalBool(it.ReturnsAccepted, "returnsAccepted")
 	if err != nil {
-		return eBayItem{}, fmt.Errorf("cannot convert conditionID to int: %w", err)
+		return eBayItem{}, err
 	}
-	isMultiVariationListing, err := strconv.ParseBool(it.IsMultiVariationListing[0])
+	cond := first(it.Condition)
+	listing := first(it.ListingInfo)
+	category := first(it.PrimaryCategory)
+	status := first(it.SellingStatus)
+	shipping := first(it.ShippingInfo)
+	conditionID, err := optionalInt(cond.ConditionID, "conditionID")
 	if err != nil {
-		return eBayItem{}, fmt.Errorf("cannot convert isMultiVariationListing to bool: %w", err)
+		return eBayItem{}, err
 	}
-	itemID, err := strconv.ParseInt(it.ItemID[0], 10, 64)
+	country, err := elem(it.Country, "country")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	var distanceUnit *string
+	var distanceValue *float64
+	if len(it.Distance) > 0 {
+		distanceUnit = &it.Distance[0].Unit
+		var v float64
+		v, err = strconv.ParseFloat(it.Distance[0].Value, 64)
+		if err != nil {
+			return eBayItem{}, fmt.Errorf("cannot convert distance value to float64: %w", err)
+		}
+		distanceValue = &v
+	}
+	expeditedShipping, err := optionalBool(shipping.ExpeditedShipping, "expeditedShipping")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	globalID, err := elem(it.GlobalID, "globalID")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	handlingTime, err := optionalInt(shipping.HandlingTime, "handlingTime")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	isMultiVariationListing, err := requiredBool(it.IsMultiVariationListing, "isMultiVariationListing")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	id, err := elem(it.ItemID, "itemID")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	itemID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
 		return eBayItem{}, fmt.Errorf("cannot convert itemID to int64: %w", err)
 	}
-	bestOfferEnabled, err := strconv.ParseBool(it.ListingInfo[0].BestOfferEnabled[0])
+	bestOfferEnabled, err := requiredBool(listing.BestOfferEnabled, "bestOfferEnabled")
 	if err != nil {
-		return eBayItem{}, fmt.Errorf("cannot convert bestOfferEnabled to bool: %w", err)
+		return eBayItem{}, err
 	}
-	buyItNowAvailable, err := strconv.ParseBool(it.ListingInfo[0].BuyItNowAvailable[0])
+	buyItNowAvailable, err := requiredBool(listing.BuyItNowAvailable, "buyItNowAvailable")
 	if err != nil {
-		return eBayItem{}, fmt.Errorf("cannot convert buyItNowAvailable to bool: %w", err)
+		return eBayItem{}, err
 	}
-	var watchCount *int
-	if len(it.ListingInfo[0].WatchCount) > 0 {
-		var v int
-		v, err = strconv.Atoi(it.ListingInfo[0].WatchCount[0])
-		if err != nil {
-			return eBayItem{}, fmt.Errorf("cannot convert watchCount to int: %w", err)
-		}
-		watchCount = &v
+	endTime, err := elem(listing.EndTime, "endTime")
+	if err != nil {
+		return eBayItem{}, err
 	}
-	primaryCategoryID, err := strconv.Atoi(it.PrimaryCategory[0].CategoryID[0])
+	gift, err := optionalBool(listing.Gift, "gift")
 	if err != nil {
-		return eBayItem{}, fmt.Errorf("cannot convert primaryCategoryID to int: %w", err)
+		return eBayItem{}, err
 	}
-	var productIDType *string
-	var productIDValue *int64
-	if len(it.ProductID) > 0 {
-		productIDType = &it.ProductID[0].Type
-		var v int64
-		v, err = strconv.ParseInt(it.ProductID[0].Value, 10, 64)
-		if err != nil {
-			return eBayItem{}, fmt.Errorf("cannot convert productID value to int64: %w", err)
-		}
-		productIDValue = &v
+	listingType, err := elem(listing.ListingType, "listingType")
+	if err != nil {
+		return eBayItem{}, err
 	}
-	var sellingStatusSellingState, sellingStatusTimeLeft *string
-	if len(it.SellingStatus[0].SellingState) > 0 {
-		sellingStatusSellingState = &it.SellingStatus[0].SellingState[0]
-		sellingStatusTimeLeft = &it.SellingStatus[0].TimeLeft[0]
+	startTime, err := elem(listing.StartTime, "startTime")
+	if err != nil {
+		return eBayItem{}, err
 	}
-	var sellingStatusPriceCurrency, sellingStatusConvertedPriceCurrency *string
-	var sellingStatusPriceValue, sellingStatusConvertedPriceValue *float64
-	if len(it.SellingStatus[0].CurrentPrice) > 0 {
-		sellingStatusPriceCurrency = &it.SellingStatus[0].CurrentPrice[0].CurrencyID
-		var v float64
-		v, err = strconv.ParseFloat(it.SellingStatus[0].CurrentPrice[0].Value, 64)
-		if err != nil {
-			return eBayItem{}, fmt.Errorf("cannot convert selling status current price value to float64: %w", err)
+	watchCount, err := optionalInt(listing.WatchCount, "watchCount")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	oneDayShippingAvailable, err := optionalBool(shipping.OneDayShippingAvailable, "oneDayShippingAvailable")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	primaryCategoryID, err := requiredInt(category.CategoryID, "primaryCategoryID")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	primaryCategoryName, err := elem(category.CategoryName, "primaryCategoryName")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	// Product IDs are stored as text, since reference IDs need not be numeric.
+	var productIDType, productIDValue *string
+	if len(it.ProductID) > 0 {
+		if it.ProductID[0].Type != "" {
+			productIDType = &it.ProductID[0].Type
 		}
-		sellingStatusPriceValue = &v
-		sellingStatusConvertedPriceCurrency = &it.SellingStatus[0].ConvertedCurrentPrice[0].CurrencyID
-		v, err = strconv.ParseFloat(it.SellingStatus[0].ConvertedCurrentPrice[0].Value, 64)
-		if err != nil {
-			return eBayItem{}, fmt.Errorf("cannot convert selling status converted current price value to float64: %w", err)
+		if it.ProductID[0].Value != "" {
+			productIDValue = &it.ProductID[0].Value
 		}
-		sellingStatusConvertedPriceValue = &v
 	}
-	var shippingServiceCurrency, shippingType, shipToLocations *string
-	var shippingServiceValue *float64
-	if len(it.ShippingInfo[0].ShippingServiceCost) > 0 {
-		shippingServiceCurrency = &it.ShippingInfo[0].ShippingServiceCost[0].CurrencyID
-		var v float64
-		v, err = strconv.ParseFloat(it.ShippingInfo[0].ShippingServiceCost[0].Value, 64)
-		if err != nil {
-			return eBayItem{}, fmt.Errorf("cannot convert shipping service cost value to float64: %w", err)
-		}
-		shippingServiceValue = &v
-		shippingType = &it.ShippingInfo[0].ShippingType[0]
-		shipToLocations = &it.ShippingInfo[0].ShipToLocations[0]
+	seller := first(it.SellerInfo)
+	feedbackScore, err := optionalInt(seller.FeedbackScore, "feedbackScore")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	positiveFeedbackPercent, err := optionalFloat(seller.PositiveFeedbackPercent, "positiveFeedbackPercent")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	topRatedSeller, err := optionalBool(seller.TopRatedSeller, "topRatedSeller")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	sellingStatusPriceCurrency, sellingStatusPriceValue, err := firstPrice(status.CurrentPrice)
+	if err != nil {
+		return eBayItem{}, fmt.Errorf("cannot convert selling status current price value to float64: %w", err)
+	}
+	sellingStatusConvertedPriceCurrency, sellingStatusConvertedPriceValue, err := firstPrice(status.ConvertedCurrentPrice)
+	if err != nil {
+		return eBayItem{}, fmt.Errorf("cannot convert selling status converted current price value to float64: %w", err)
 	}
-	topRatedListing, err := strconv.ParseBool(it.TopRatedListing[0])
+	shippingServiceCurrency, shippingServiceValue, err := firstPrice(shipping.ShippingServiceCost)
 	if err != nil {
-		return eBayItem{}, fmt.Errorf("cannot convert topRatedListing to bool: %w", err)
+		return eBayItem{}, fmt.Errorf("cannot convert shipping service cost value to float64: %w", err)
+	}
+	topRatedListing, err := requiredBool(it.TopRatedListing, "topRatedListing")
+	if err != nil {
+		return eBayItem{}, err
+	}
+	title, err := elem(it.Title, "title")
+	if err != nil {
+		return eBayItem{}, err
 	}
 	return eBayItem{
-		conditionDisplayName:         it.Condition[0].ConditionDisplayName[0],
-		conditionID:                  conditionID,
-		country:                      it.Country[0],
-		galleryURL:                   firstElem(it.GalleryURL),
-		globalID:                     it.GlobalID[0],
-		isMultiVariationListing:      isMultiVariationListing,
-		itemID:                       itemID,
-		listingInfoBestOfferEnabled:  bestOfferEnabled,
-		listingInfoBuyItNowAvailable: buyItNowAvailable,
-		listingInfoEndTime:           it.ListingInfo[0].EndTime[0],
-		listingInfoListingType:       it.ListingInfo[0].ListingType[0],
-		listingInfoStartTime:         it.ListingInfo[0].StartTime[0],
-		listingInfoWatchCount:        watchCount,
-		location:                     firstElem(it.Location),
-		postalCode:                   firstElem(it.PostalCode),
-		primaryCategoryID:            primaryCategoryID,
-		primaryCategoryName:          it.PrimaryCategory[0].CategoryName[0],
-		productIDType:                productIDType,
-		productIDValue:               productIDValue,
+		autoPay:                       autoPay,
+		conditionDisplayName:          firstElem(cond.ConditionDisplayName),
+		conditionID:                   conditionID,
+		country:                       country,
+		distanceUnit:                  distanceUnit,
+		distanceValue:                 distanceValue,
+		expeditedShipping:             expeditedShipping,
+		galleryURL:                    firstElem(it.GalleryURL),
+		galleryURLLarge:               galleryURL(it.GalleryInfoContainer, "Large"),
+		galleryURLMedium:              galleryURL(it.GalleryInfoContainer, "Medium"),
+		galleryURLSmall:               galleryURL(it.GalleryInfoContainer, "Small"),
+		globalID:                      globalID,
+		handlingTime:                  handlingTime,
+		isMultiVariationListing:       isMultiVariationListing,
+		itemID:                        itemID,
+		listingInfoBestOfferEnabled:   bestOfferEnabled,
+		listingInfoBuyItNowAvailable:  buyItNowAvailable,
+		listingInfoEndTime:            endTime,
+		listingInfoGift:               gift,
+		listingInfoListingType:        listingType,
+		listingInfoStartTime:          startTime,
+		listingInfoWatchCount:         watchCount,
+		location:                      firstElem(it.Location),
+		oneDayShippingAvailable:       oneDayShippingAvailable,
+		pictureURLs:                   pictureURLs(it),
+		postalCode:                    firstElem(it.PostalCode),
+		primaryCategoryID:             primaryCategoryID,
+		primaryCategoryName:           primaryCategoryName,
+		productIDType:                 productIDType,
+		productIDValue:                productIDValue,
+		returnsAccepted:               returnsAccepted,
+		sellerFeedbackScore:           feedbackScore,
+		sellerPositiveFeedbackPercent: positiveFeedbackPercent,
+		sellerTopRated:                topRatedSeller,
+		sellerUserName:                firstElem(seller.SellerUserName),
 		sellingStatusConvertedCurrentPriceCurrency: sellingStatusConvertedPriceCurrency,
 		sellingStatusConvertedCurrentPriceValue:    sellingStatusConvertedPriceValue,
 		sellingStatusCurrentPriceCurrency:          sellingStatusPriceCurrency,
 		sellingStatusCurrentPriceValue:             sellingStatusPriceValue,
-		sellingStatusSellingState:                  sellingStatusSellingState,
-		sellingStatusTimeLeft:                      sellingStatusTimeLeft,
+		sellingStatusSellingState:                  firstElem(status.SellingState),
+		sellingStatusTimeLeft:                      firstElem(status.TimeLeft),
 		shippingServiceCostCurrency:                shippingServiceCurrency,
 		shippingServiceCostValue:                   shippingServiceValue,
-		shippingType:                               shippingType,
-		shipToLocations:                            shipToLocations,
+		shippingType:                               firstElem(shipping.ShippingType),
+		shipToLocations:                            shipping.ShipToLocations,
 		subtitle:                                   firstElem(it.Subtitle),
-		title:                                      it.Title[0],
+		title:                                      title,
 		topRatedListing:                            topRatedListing,
 		viewItemURL:                                firstElem(it.ViewItemURL),
 	}, nil
 }
 
+// first returns the first element of xs, or the zero value if xs is empty.
+func first[T any](xs []T) T {
+	var zero T
+	if len(xs) > 0 {
+		return xs[0]
+	}
+	return zero
+}
+
+// elem returns the first element of xs, or an error naming the missing field
+// if xs is empty.
+func elem[T any](xs []T, name string) (T, error) {
+	var zero T
+	if len(xs) == 0 {
+		return zero, fmt.Errorf("missing %s", name)
+	}
+	return xs[0], nil
+}
+
+func requiredInt(ss []string, name string) (int, error) {
+	s, err := elem(ss, name)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot convert %s to int: %w", name, err)
+	}
+	return v, nil
+}
+
+func requiredBool(ss []string, name string) (bool, error) {
+	s, err := elem(ss, name)
+	if err != nil {
+		return false, err
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("cannot convert %s to bool: %w", name, err)
+	}
+	return v, nil
+}
+
+func optionalInt(ss []string, name string) (*int, error) {
+	if len(ss) == 0 {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(ss[0])
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %s to int: %w", name, err)
+	}
+	return &v, nil
+}
+
+func optionalBool(ss []string, name string) (*bool, error) {
+	if len(ss) == 0 {
+		return nil, nil
+	}
+	v, err := strconv.ParseBool(ss[0])
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %s to bool: %w", name, err)
+	}
+	return &v, nil
+}
+
+func optionalFloat(ss []string, name string) (*float64, error) {
+	if len(ss) == 0 {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(ss[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %s to float64: %w", name, err)
+	}
+	return &v, nil
+}
+
 func firstElem(ss []string) *string {
 	if len(ss) > 0 {
 		return &ss[0]
 	}
 	return nil
 }
+
+// pictureURLs returns the item's large, super size, and Gallery Plus picture
+// URLs without duplicates. They are only present if the PictureURLLarge,
+// PictureURLSuperSize, or GalleryInfo output selectors were requested.
+func pictureURLs(it ebay.SearchItem) []string {
+	var urls []string
+	for _, u := range slices.Concat(it.PictureURLLarge, it.PictureURLSuperSize, it.GalleryPlusPictureURL) {
+		if u != "" && !slices.Contains(urls, u) {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// galleryURL returns the gallery thumbnail URL of the given size, or nil if the
+// GalleryInfo output selector was not requested or the size is missing.
+func galleryURL(gs []ebay.GalleryURL, size string) *string {
+	for i := range gs {
+		if gs[i].GallerySize == size {
+			return &gs[i].Value
+		}
+	}
+	return nil
+}
+
+func firstPrice(ps []ebay.Price) (*string, *float64, error) {
+	if len(ps) == 0 {
+		return nil, nil, nil
+	}
+	v, err := strconv.ParseFloat(ps[0].Value, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ps[0].CurrencyID, &v, nil
+}
@@ -0,0 +1,402 @@
+// Copyright 2024 Matthew P. Dargan.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matthewdargan/ebay"
+)
+
+var testTime = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// testItem returns a search item with every required field set.
+func testItem(id string) ebay.SearchItem {
+	return ebay.SearchItem{
+		Condition:               []ebay.Condition{{ConditionDisplayName: []string{"New"}, ConditionID: []string{"1000"}}},
+		Country:                 []string{"US"},
+		GlobalID:                []string{"EBAY-US"},
+		IsMultiVariationListing: []string{"false"},
+		ItemID:                  []string{id},
+		ListingInfo: []ebay.ListingInfo{{
+			BestOfferEnabled:  []string{"false"},
+			BuyItNowAvailable: []string{"false"},
+			EndTime:           []time.Time{testTime.Add(24 * time.Hour)},
+			ListingType:       []string{"FixedPrice"},
+			StartTime:         []time.Time{testTime},
+		}},
+		PrimaryCategory: []ebay.Category{{CategoryID: []string{"9355"}, CategoryName: []string{"Cell Phones & Smartphones"}}},
+		Title:           []string{"phone"},
+		TopRatedListing: []string{"false"},
+	}
+}
+
+func testResponse(items ...ebay.SearchItem) ebay.FindItemsResponse {
+	return ebay.FindItemsResponse{
+		SearchResult: []ebay.SearchResult{{Item: items}},
+		Timestamp:    []time.Time{testTime},
+		Version:      []string{"1.13.0"},
+	}
+}
+
+func TestResponseToItems(t *testing.T) {
+	t.Parallel()
+	t.Run("EmptySearchResult", func(t *testing.T) {
+		t.Parallel()
+		got, err := responseToItems(ebay.FindItemsResponse{}, false)
+		if err != nil {
+			t.Errorf("responseToItems() error = %v, want nil", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("responseToItems() = %v, want no items", got)
+		}
+	})
+
+	t.Run("EmptyItem", func(t *testing.T) {
+		t.Parallel()
+		resp := ebay.FindItemsResponse{SearchResult: []ebay.SearchResult{{Count: "0"}}}
+		got, err := responseToItems(resp, false)
+		if err != nil {
+			t.Errorf("responseToItems() error = %v, want nil", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("responseToItems() = %v, want no items", got)
+		}
+	})
+
+	t.Run("MissingTimestamp", func(t *testing.T) {
+		t.Parallel()
+		resp := testResponse(testItem("1"))
+		resp.Timestamp = nil
+		if _, err := responseToItems(resp, false); err == nil {
+			t.Error("responseToItems() error = nil, want missing timestamp")
+		}
+	})
+
+	t.Run("MissingCondition", func(t *testing.T) {
+		t.Parallel()
+		it := testItem("1")
+		it.Condition = nil
+		got, err := responseToItems(testResponse(it), false)
+		if err != nil {
+			t.Fatalf("responseToItems() error = %v, want nil", err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("responseToItems() returned %d items, want 1", len(got))
+		}
+		if got[0].conditionDisplayName != nil || got[0].conditionID != nil {
+			t.Errorf("responseToItems() condition = %v, %v, want nil", got[0].conditionDisplayName, got[0].conditionID)
+		}
+	})
+
+	t.Run("MissingListingInfo", func(t *testing.T) {
+		t.Parallel()
+		bad := testItem("1")
+		bad.ListingInfo = nil
+		got, err := responseToItems(testResponse(bad, testItem("2")), false)
+		if err != nil {
+			t.Fatalf("responseToItems() error = %v, want nil", err)
+		}
+		if len(got) != 1 || got[0].itemID != 2 {
+			t.Errorf("responseToItems() = %v, want only item 2", got)
+		}
+	})
+}
+
+func TestFindPages(t *testing.T) {
+	t.Parallel()
+	var pages []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("paginationInput.pageNumber")
+		pages = append(pages, page)
+		resp := testResponse(testItem("1"), testItem("2"), testItem("3"))
+		resp.PaginationOutput = []ebay.PaginationOutput{{PageNumber: []string{page}, TotalPages: []string{"10"}}}
+		err := json.NewEncoder(w).Encode(&ebay.FindItemsByKeywordsResponse{ItemsResponse: []ebay.FindItemsResponse{resp}})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}))
+	defer ts.Close()
+	client := ebay.NewFindingClient(ts.Client(), "ebay-app-id")
+	client.URL = ts.URL
+	params := map[string]string{"keywords": "phone", "paginationInput.pageNumber": "4"}
+	resps, err := findPages(context.Background(), client, "keyword", params, 7)
+	if err != nil {
+		t.Fatalf("findPages() error = %v, want nil", err)
+	}
+	if want := []string{"4", "5", "6"}; !slices.Equal(pages, want) {
+		t.Errorf("findPages() requested pages %v, want %v", pages, want)
+	}
+	if n := len(responsesToItems(resps, false)); n != 7 {
+		t.Errorf("findPages() returned %d items, want 7", n)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	t.Parallel()
+	all := strings.Join(requiredColumns, ",")
+	tests := []struct {
+		name    string
+		s       string
+		wantErr bool
+	}{
+		{"Required", all, false},
+		{"Optional", all + ", view_item_url", false},
+		{"Unknown", all + ",price", true},
+		{"Duplicate", all + ",title", true},
+		{"MissingRequired", "item_id,title", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := parseColumns(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseColumns(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+	items, err := responseToItems(testResponse(testItem("1")), false)
+	if err != nil {
+		t.Fatalf("responseToItems() error = %v, want nil", err)
+	}
+	it := items[0]
+	later := it
+	later.timestamp = it.timestamp.Add(time.Hour)
+	later.sellingStatusTimeLeft = firstElem([]string{"P0DT1H0M0S"})
+	if it.Hash() != later.Hash() {
+		t.Error("Hash() differs for items that differ only in unhashed columns")
+	}
+	empty := it
+	empty.location = firstElem([]string{""})
+	if it.Hash() == empty.Hash() {
+		t.Error("Hash() is equal for missing and empty location")
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		ps      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "Single",
+			ps:   "keywords=phone&paginationInput.entriesPerPage=10",
+			want: map[string]string{"keywords": "phone", "paginationInput.entriesPerPage": "10"},
+		},
+		{
+			name: "Escaped",
+			ps:   "keywords=iphone%2015+pro",
+			want: map[string]string{"keywords": "iphone 15 pro"},
+		},
+		{
+			name: "JSON",
+			ps:   `{"keywords": "phone", "itemFilter(0).name": "Condition"}`,
+			want: map[string]string{"keywords": "phone", "itemFilter(0).name": "Condition"},
+		},
+		{
+			name: "RepeatedKey",
+			ps:   "outputSelector=SellerInfo&outputSelector=GalleryInfo",
+			want: map[string]string{"outputSelector(0)": "SellerInfo", "outputSelector(1)": "GalleryInfo"},
+		},
+		{
+			name: "SingleItemFilter",
+			ps:   "itemFilter.name=Condition&itemFilter.value=New",
+			want: map[string]string{"itemFilter.name": "Condition", "itemFilter.value": "New"},
+		},
+		{
+			name: "ItemFilters",
+			ps:   "itemFilter.name=Condition&itemFilter.value=New&itemFilter.value=Used&itemFilter.name=FreeShippingOnly&itemFilter.value=true",
+			want: map[string]string{
+				"itemFilter(0).name":     "Condition",
+				"itemFilter(0).value(0)": "New",
+				"itemFilter(0).value(1)": "Used",
+				"itemFilter(1).name":     "FreeShippingOnly",
+				"itemFilter(1).value":    "true",
+			},
+		},
+		{
+			name: "AspectFilters",
+			ps:   "aspectFilter.aspectName=Color&aspectFilter.aspectValueName=Black&aspectFilter.aspectName=Storage&aspectFilter.aspectValueName=128GB",
+			want: map[string]string{
+				"aspectFilter(0).aspectName":      "Color",
+				"aspectFilter(0).aspectValueName": "Black",
+				"aspectFilter(1).aspectName":      "Storage",
+				"aspectFilter(1).aspectValueName": "128GB",
+			},
+		},
+		{name: "FieldBeforeName", ps: "itemFilter.value=New&itemFilter.name=Condition&itemFilter.name=X&itemFilter.value=1", wantErr: true},
+		{name: "FieldBeforeAspectName", ps: "aspectFilter.aspectValueName=Black&aspectFilter.aspectName=Color", wantErr: true},
+		{name: "NumberedClash", ps: "outputSelector=A&outputSelector=B&outputSelector(0)=C", wantErr: true},
+		{name: "FilterClash", ps: "itemFilter.name=A&itemFilter.name=B&itemFilter(1).name=C", wantErr: true},
+		{name: "MissingValue", ps: "keywords", wantErr: true},
+		{name: "EmptyKey", ps: "=phone", wantErr: true},
+		{name: "InvalidEscape", ps: "keywords=%zz", wantErr: true},
+		{name: "InvalidJSON", ps: `{"keywords": 1}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseParams(tt.ps)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseParams(%q) error = %v, wantErr %v", tt.ps, err, tt.wantErr)
+			}
+			if !tt.wantErr && !maps.Equal(got, tt.want) {
+				t.Errorf("parseParams(%q) = %v, want %v", tt.ps, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "Duration", s: "24h", want: testTime.Add(-24 * time.Hour)},
+		{name: "RFC3339", s: "2024-05-01T00:00:00Z", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "Now", s: "0s", want: testTime},
+		{name: "FutureTime", s: "2024-07-01T00:00:00Z", wantErr: true},
+		{name: "NegativeDuration", s: "-1h", wantErr: true},
+		{name: "Invalid", s: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseSince(tt.s, testTime)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSince(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseSince(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddItemFilter(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "NoFilters",
+			params: map[string]string{"keywords": "phone"},
+			want: map[string]string{
+				"keywords":            "phone",
+				"itemFilter(0).name":  "ModTimeFrom",
+				"itemFilter(0).value": "2024-06-01T12:00:00.000Z",
+			},
+		},
+		{
+			name:   "NonNumbered",
+			params: map[string]string{"itemFilter.name": "Condition", "itemFilter.value": "New"},
+			want: map[string]string{
+				"itemFilter(0).name":  "Condition",
+				"itemFilter(0).value": "New",
+				"itemFilter(1).name":  "ModTimeFrom",
+				"itemFilter(1).value": "2024-06-01T12:00:00.000Z",
+			},
+		},
+		{
+			name: "Numbered",
+			params: map[string]string{
+				"itemFilter(0).name":     "Condition",
+				"itemFilter(0).value(0)": "New",
+				"itemFilter(2).name":     "FreeShippingOnly",
+				"itemFilter(2).value":    "true",
+			},
+			want: map[string]string{
+				"itemFilter(0).name":     "Condition",
+				"itemFilter(0).value(0)": "New",
+				"itemFilter(2).name":     "FreeShippingOnly",
+				"itemFilter(2).value":    "true",
+				"itemFilter(3).name":     "ModTimeFrom",
+				"itemFilter(3).value":    "2024-06-01T12:00:00.000Z",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			addItemFilter(tt.params, "ModTimeFrom", testTime.Format(ebayTimeLayout))
+			if !maps.Equal(tt.params, tt.want) {
+				t.Errorf("addItemFilter() = %v, want %v", tt.params, tt.want)
+			}
+		})
+	}
+}
+
+// statusError returns the error a FindingClient returns for an HTTP response
+// with the given status code.
+func statusError(t *testing.T, code int) error {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(code)
+	}))
+	defer ts.Close()
+	client := ebay.NewFindingClient(ts.Client(), "ebay-app-id")
+	client.URL = ts.URL
+	_, err := client.FindItemsByKeywords(context.Background(), map[string]string{"keywords": "phone"})
+	if err == nil {
+		t.Fatalf("FindingClient.FindItemsByKeywords() error = nil, want status %d", code)
+	}
+	return err
+}
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+	failure := func(categories ...string) error {
+		var es []ebay.ErrorData
+		for _, c := range categories {
+			es = append(es, ebay.ErrorData{Category: []string{c}})
+		}
+		return &failureError{[]ebay.ErrorMessage{{Error: es}}}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"BadRequest", statusError(t, http.StatusBadRequest), exitInvalid},
+		{"NotFound", statusError(t, http.StatusNotFound), exitInvalid},
+		{"InternalServerError", statusError(t, http.StatusInternalServerError), exitAPI},
+		{"ServiceUnavailable", statusError(t, http.StatusServiceUnavailable), exitAPI},
+		{"SystemFailure", failure("Request", "System"), exitAPI},
+		{"RequestFailure", failure("Request"), exitInvalid},
+		{"FailedRequest", fmt.Errorf("%w: connection refused", ebay.ErrFailedRequest), exitAPI},
+		{"DecodeAPIResponse", fmt.Errorf("%w: unexpected EOF", ebay.ErrDecodeAPIResponse), exitAPI},
+		{"NewRequest", fmt.Errorf("%w: invalid URL", ebay.ErrNewRequest), exitError},
+		{"Other", errors.New("database failure"), exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 Matthew P. Dargan.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	outputCSV  = "csv"
+	outputJSON = "json"
+)
+
+func writeItems(w io.Writer, items []eBayItem, format string) error {
+	switch format {
+	case outputJSON:
+		if items == nil {
+			items = []eBayItem{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(items)
+	case outputCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(itemColumns); err != nil {
+			return err
+		}
+		for _, it := range items {
+			if err := cw.Write(it.record()); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// newTemplate parses text as a template for formatting one item.
+// The template's data is a map from database column name to value, with
+// missing optional fields set to nil. The default function returns its first
+// argument if the second is nil, as in {{default "unknown" .location}}.
+func newTemplate(text string) (*template.Template, error) {
+	return template.New("item").Option("missingkey=error").Funcs(template.FuncMap{
+		"default": func(def, v any) any {
+			if v == nil {
+				return def
+			}
+			return v
+		},
+	}).Parse(text)
+}
+
+// writeTemplate executes t for each item, writing a newline after each.
+func writeTemplate(w io.Writer, items []eBayItem, t *template.Template) error {
+	for _, it := range items {
+		if err := t.Execute(w, it.fields()); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fields returns the item's values keyed by database column name, with
+// pointers dereferenced and nil pointers replaced by nil.
+func (it eBayItem) fields() map[string]any {
+	vs := it.values()
+	m := make(map[string]any, len(itemColumns))
+	for i, c := range itemColumns {
+		m[c] = deref(vs[i])
+	}
+	return m
+}
+
+// deref returns the value v points to, or nil if v is a nil pointer.
+// Other values are returned unchanged.
+func deref(v any) any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer {
+		return v
+	}
+	if rv.IsNil() {
+		return nil
+	}
+	return rv.Elem().Interface()
+}
+
+// MarshalJSON encodes the item as an object keyed by database column name.
+// Missing optional fields are encoded as null.
+func (it eBayItem) MarshalJSON() ([]byte, error) {
+	vs := it.values()
+	m := make(map[string]any, len(itemColumns))
+	for i, c := range itemColumns {
+		m[c] = vs[i]
+	}
+	return json.Marshal(m)
+}
+
+// record formats the item as a CSV row in itemColumns order.
+// Missing optional fields are empty, times are formatted as RFC 3339, and
+// lists are separated by spaces.
+func (it eBayItem) record() []string {
+	vs := it.values()
+	rec := make([]string, len(vs))
+	for i, v := range vs {
+		switch v := deref(v).(type) {
+		case nil:
+		case time.Time:
+			rec[i] = v.Format(time.RFC3339)
+		case float64:
+			rec[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case []string:
+			rec[i] = strings.Join(v, " ")
+		default:
+			rec[i] = fmt.Sprint(v)
+		}
+	}
+	return rec
+}
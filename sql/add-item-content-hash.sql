@@ -0,0 +1 @@
+ALTER TABLE item ADD COLUMN content_hash TEXT;
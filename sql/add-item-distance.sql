@@ -0,0 +1,3 @@
+ALTER TABLE item
+    ADD COLUMN distance_unit TEXT,
+    ADD COLUMN distance_value NUMERIC;
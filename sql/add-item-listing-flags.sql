@@ -0,0 +1,4 @@
+ALTER TABLE item
+    ADD COLUMN auto_pay BOOLEAN,
+    ADD COLUMN listing_info_gift BOOLEAN,
+    ADD COLUMN returns_accepted BOOLEAN;
@@ -0,0 +1 @@
+ALTER TABLE item ADD COLUMN picture_urls TEXT[];
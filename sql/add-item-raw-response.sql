@@ -0,0 +1 @@
+ALTER TABLE item ADD COLUMN raw_response JSONB;
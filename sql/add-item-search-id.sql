@@ -0,0 +1 @@
+ALTER TABLE item ADD COLUMN search_id INT REFERENCES search (id);
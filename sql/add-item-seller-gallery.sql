@@ -0,0 +1,6 @@
+ALTER TABLE item
+    ADD COLUMN gallery_url_large TEXT,
+    ADD COLUMN gallery_url_medium TEXT,
+    ADD COLUMN gallery_url_small TEXT,
+    ADD COLUMN seller_feedback_score INT,
+    ADD COLUMN seller_positive_feedback_percent NUMERIC;
@@ -0,0 +1,3 @@
+ALTER TABLE item
+    ADD COLUMN seller_top_rated BOOLEAN,
+    ADD COLUMN seller_user_name TEXT;
@@ -0,0 +1,4 @@
+ALTER TABLE item
+    ADD COLUMN expedited_shipping BOOLEAN,
+    ADD COLUMN handling_time INT,
+    ADD COLUMN one_day_shipping_available BOOLEAN;
@@ -0,0 +1,3 @@
+ALTER TABLE item
+    ALTER COLUMN condition_display_name DROP NOT NULL,
+    ALTER COLUMN condition_id DROP NOT NULL;
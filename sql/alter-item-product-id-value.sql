@@ -0,0 +1,2 @@
+ALTER TABLE item
+    ALTER COLUMN product_id_value TYPE TEXT USING product_id_value::TEXT;
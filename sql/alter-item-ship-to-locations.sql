@@ -0,0 +1,3 @@
+ALTER TABLE item
+    ALTER COLUMN ship_to_locations TYPE TEXT[]
+    USING CASE WHEN ship_to_locations IS NULL THEN NULL ELSE ARRAY[ship_to_locations] END;
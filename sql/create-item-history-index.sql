@@ -0,0 +1 @@
+CREATE UNIQUE INDEX item_item_id_timestamp_idx ON item (item_id, timestamp);
@@ -2,25 +2,42 @@ CREATE TABLE item (
     id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
     timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
     version TEXT NOT NULL,
-    condition_display_name TEXT NOT NULL,
-    condition_id INT NOT NULL,
+    auto_pay BOOLEAN,
+    condition_display_name TEXT,
+    condition_id INT,
+    content_hash TEXT,
     country TEXT NOT NULL,
+    distance_unit TEXT,
+    distance_value NUMERIC,
+    expedited_shipping BOOLEAN,
     gallery_url TEXT,
+    gallery_url_large TEXT,
+    gallery_url_medium TEXT,
+    gallery_url_small TEXT,
     global_id TEXT NOT NULL,
+    handling_time INT,
     is_multi_variation_listing BOOLEAN NOT NULL,
     item_id BIGINT NOT NULL,
     listing_info_best_offer_enabled BOOLEAN NOT NULL,
     listing_info_buy_it_now_available BOOLEAN NOT NULL,
     listing_info_end_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    listing_info_gift BOOLEAN,
     listing_info_listing_type TEXT NOT NULL,
     listing_info_start_time TIMESTAMP WITH TIME ZONE NOT NULL,
     listing_info_watch_count INT,
     location TEXT,
+    one_day_shipping_available BOOLEAN,
+    picture_urls TEXT[],
     postal_code TEXT,
     primary_category_id BIGINT NOT NULL,
     primary_category_name TEXT NOT NULL,
     product_id_type TEXT,
-    product_id_value BIGINT,
+    product_id_value TEXT,
+    returns_accepted BOOLEAN,
+    seller_feedback_score INT,
+    seller_positive_feedback_percent NUMERIC,
+    seller_top_rated BOOLEAN,
+    seller_user_name TEXT,
     selling_status_converted_current_price_currency TEXT,
     selling_status_converted_current_price_value NUMERIC,
     selling_status_current_price_currency TEXT,
@@ -30,9 +47,11 @@ CREATE TABLE item (
     shipping_service_cost_currency TEXT,
     shipping_service_cost_value NUMERIC,
     shipping_type TEXT,
-    ship_to_locations TEXT,
+    ship_to_locations TEXT[],
     subtitle TEXT,
     title TEXT NOT NULL,
     top_rated_listing BOOLEAN NOT NULL,
-    view_item_url TEXT
+    view_item_url TEXT,
+    search_id INT REFERENCES search (id),
+    raw_response JSONB
 );
@@ -0,0 +1,7 @@
+CREATE TABLE search (
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+    method TEXT NOT NULL,
+    params JSONB NOT NULL,
+    result_count INT NOT NULL
+);